@@ -13,20 +13,24 @@ func AutocompleteMain(commands []*Command) bool {
 	for _, cmd := range commands {
 		flags := make(map[string]complete.Predictor)
 		cmd.Flag.VisitAll(func(flag *flag.Flag) {
-			flags["-"+flag.Name] = complete.PredictAnything
+			flags["-"+flag.Name] = predictorForFlag(cmd, flag.Name)
 		})
 		flags["-h"] = complete.PredictNothing
 
-		subCommands[cmd.Name()] = complete.Command{
+		subCommand := complete.Command{
 			Flags: flags,
 		}
+		if cmd.PositionalPredictor != nil {
+			subCommand.Args = cmd.PositionalPredictor
+		}
+		subCommands[cmd.Name()] = subCommand
 		helpSubCommands[cmd.Name()] = complete.Command{}
 	}
 	subCommands["help"] = complete.Command{Sub: helpSubCommands}
 
 	globalFlags := make(map[string]complete.Predictor)
 	flag.VisitAll(func(flag *flag.Flag) {
-		globalFlags["-"+flag.Name] = complete.PredictAnything
+		globalFlags["-"+flag.Name] = predictorForFlag(nil, flag.Name)
 	})
 	globalFlags["-h"] = complete.PredictNothing
 
@@ -39,6 +43,22 @@ func AutocompleteMain(commands []*Command) bool {
 	return cmp.Complete()
 }
 
+// predictorForFlag resolves the completion predictor for a flag by name: a Command's own
+// FlagPredictors take precedence, then the name-based defaultFlagPredictors (so a flag named
+// e.g. "-dir" gets file completion even if the command never set FlagPredictors explicitly),
+// falling back to complete.PredictAnything. cmd may be nil when resolving weed's global flags.
+func predictorForFlag(cmd *Command, flagName string) complete.Predictor {
+	if cmd != nil {
+		if predictor, ok := cmd.FlagPredictors[flagName]; ok {
+			return predictor
+		}
+	}
+	if predictor, ok := defaultFlagPredictors[flagName]; ok {
+		return predictor
+	}
+	return complete.PredictAnything
+}
+
 func installAutoCompletion() bool {
 	err := completeinstall.Install("weed")
 	if err != nil {
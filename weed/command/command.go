@@ -0,0 +1,68 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	flag "github.com/chrislusf/seaweedfs/weed/util/fla9"
+	"github.com/posener/complete"
+)
+
+// A Command is an implementation of a weed command
+// like weed benchmark or weed shell.
+type Command struct {
+	// Run runs the command.
+	// The args are the arguments after the command name.
+	Run func(cmd *Command, args []string) bool
+
+	// UsageLine is the one-line usage message.
+	// The first word in the line is taken to be the command name.
+	UsageLine string
+
+	// Short is the short description shown in the 'weed help' output.
+	Short string
+
+	// Long is the long message shown in the 'weed help <this-command>' output.
+	Long string
+
+	// Flag is a set of flags specific to this command.
+	Flag flag.FlagSet
+
+	IsDebug *bool
+
+	// FlagPredictors maps a flag name (without the leading "-") to the shell
+	// completion predictor that should be used to suggest values for it,
+	// overriding the name-based default from defaultFlagPredictors for this
+	// command. Flags covered by neither fall back to complete.PredictAnything.
+	FlagPredictors map[string]complete.Predictor
+
+	// PositionalPredictor predicts non-flag arguments, i.e. those given after
+	// the command's flags. Nil means no suggestions are offered.
+	PositionalPredictor complete.Predictor
+}
+
+// Name returns the command's name: the first word in the usage line.
+func (c *Command) Name() string {
+	name := c.UsageLine
+	i := strings.Index(name, " ")
+	if i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+func (c *Command) Usage() {
+	fmt.Fprintf(os.Stderr, "Usage: weed %s\n\n", c.UsageLine)
+	fmt.Fprintf(os.Stderr, "Default Usage:\n")
+	c.Flag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "Description:\n")
+	fmt.Fprintf(os.Stderr, "  %s\n", strings.TrimSpace(c.Long))
+	os.Exit(2)
+}
+
+// Runnable reports whether the command can be run; otherwise
+// it is a documentation pseudo-command such as importpath.
+func (c *Command) Runnable() bool {
+	return c.Run != nil
+}
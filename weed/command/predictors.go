@@ -0,0 +1,248 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/pb"
+	"github.com/chrislusf/seaweedfs/weed/pb/master_pb"
+	"github.com/posener/complete"
+	"google.golang.org/grpc"
+)
+
+// shellCache is the on-disk completion cache, written whenever a completion
+// lookup reaches a master, so that later completions (run from a different
+// process) can still offer useful suggestions when no master is reachable.
+type shellCache struct {
+	Collections []string  `json:"collections"`
+	VolumeIds   []string  `json:"volumeIds"`
+	DiskTypes   []string  `json:"diskTypes"`
+	Racks       []string  `json:"racks"`
+	SavedAt     time.Time `json:"savedAt"`
+}
+
+const (
+	shellCacheFileName = "shell_cache.json"
+	masterCacheTTL     = 5 * time.Second
+)
+
+var (
+	predictorCacheLock sync.Mutex
+	predictorCache     *shellCache
+	predictorCacheAt   time.Time
+)
+
+// CompletionMasters holds the master addresses to query for fresh completion
+// data. It defaults to the same "localhost:9333" default used throughout weed
+// so that completion works out of the box against a locally-run cluster.
+// Subcommands that know their actually configured masters (e.g. "weed shell"'s
+// -master flag) should overwrite this before AutocompleteMain runs; if a
+// master isn't reachable, predictors fall back to the on-disk cache alone.
+var CompletionMasters = []string{"localhost:9333"}
+
+func shellCachePath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".seaweedfs", shellCacheFileName)
+}
+
+// SaveShellCache persists the latest topology summary so that tab completion
+// in other terminals/processes has something to work with. Errors are
+// intentionally swallowed: completion is a convenience, not a critical path.
+func SaveShellCache(cache *shellCache) {
+	path := shellCachePath()
+	if path == "" {
+		return
+	}
+	cache.SavedAt = time.Now()
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return
+	}
+	os.MkdirAll(filepath.Dir(path), 0755)
+	os.WriteFile(path, data, 0644)
+}
+
+func loadShellCache() *shellCache {
+	data, err := os.ReadFile(shellCachePath())
+	if err != nil {
+		return nil
+	}
+	cache := &shellCache{}
+	if err := json.Unmarshal(data, cache); err != nil {
+		return nil
+	}
+	return cache
+}
+
+// predictorSnapshot returns a (possibly stale) view of the cluster, reading
+// from the on-disk shell cache and refreshing it from the master at most
+// once every masterCacheTTL so that tab completion stays responsive.
+func predictorSnapshot() *shellCache {
+	predictorCacheLock.Lock()
+	defer predictorCacheLock.Unlock()
+
+	if predictorCache != nil && time.Since(predictorCacheAt) < masterCacheTTL {
+		return predictorCache
+	}
+
+	cache := loadShellCache()
+	if fresh := queryMasterForCache(CompletionMasters); fresh != nil {
+		cache = fresh
+		SaveShellCache(cache)
+	}
+
+	predictorCache = cache
+	predictorCacheAt = time.Now()
+	return predictorCache
+}
+
+// queryMasterForCache asks the first reachable master for its volume
+// topology and derives the set of collections, volume ids, disk types and
+// racks known to the cluster. It returns nil (rather than an error) on any
+// failure since this only feeds best-effort shell completion.
+func queryMasterForCache(masters []string) *shellCache {
+	collections := map[string]struct{}{}
+	volumeIds := map[string]struct{}{}
+	diskTypes := map[string]struct{}{}
+	racks := map[string]struct{}{}
+
+	found := false
+	for _, master := range masters {
+		err := pb.WithMasterClient(false, pb.ServerAddress(master), grpc.WithInsecure(), func(client master_pb.SeaweedClient) error {
+			resp, err := client.VolumeList(context.Background(), &master_pb.VolumeListRequest{})
+			if err != nil {
+				return err
+			}
+			collectFromTopology(resp.TopologyInfo, collections, volumeIds, diskTypes, racks)
+			return nil
+		})
+		if err == nil {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	return &shellCache{
+		Collections: toSortedSlice(collections),
+		VolumeIds:   toSortedSlice(volumeIds),
+		DiskTypes:   toSortedSlice(diskTypes),
+		Racks:       toSortedSlice(racks),
+	}
+}
+
+func collectFromTopology(topo *master_pb.TopologyInfo, collections, volumeIds, diskTypes, racks map[string]struct{}) {
+	if topo == nil {
+		return
+	}
+	for _, dc := range topo.DataCenterInfos {
+		for _, rack := range dc.RackInfos {
+			racks[rack.Id] = struct{}{}
+			for _, dn := range rack.DataNodeInfos {
+				for diskType, diskInfo := range dn.DiskInfos {
+					diskTypes[diskType] = struct{}{}
+					for _, v := range diskInfo.VolumeInfos {
+						collections[v.Collection] = struct{}{}
+						volumeIds[strconv.Itoa(int(v.Id))] = struct{}{}
+					}
+				}
+			}
+		}
+	}
+}
+
+func toSortedSlice(set map[string]struct{}) []string {
+	out := make([]string, 0, len(set))
+	for v := range set {
+		out = append(out, v)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// PredictCollections completes a -collection flag from the cached cluster state.
+func PredictCollections() complete.Predictor {
+	return complete.PredictFunc(func(complete.Args) []string {
+		cache := predictorSnapshot()
+		if cache == nil {
+			return nil
+		}
+		return cache.Collections
+	})
+}
+
+// PredictVolumeIds completes a -volumeId flag from the cached cluster state.
+func PredictVolumeIds() complete.Predictor {
+	return complete.PredictFunc(func(complete.Args) []string {
+		cache := predictorSnapshot()
+		if cache == nil {
+			return nil
+		}
+		return cache.VolumeIds
+	})
+}
+
+// PredictDiskTypes completes a -diskType/-fromDiskType/-toDiskType flag.
+// The common disk types are always offered; any additional types seen by
+// the cluster are appended from the cache.
+func PredictDiskTypes() complete.Predictor {
+	return complete.PredictFunc(func(complete.Args) []string {
+		types := []string{"hdd", "ssd", "nvme"}
+		if cache := predictorSnapshot(); cache != nil {
+			// the cluster reports hdd under the empty disk type name
+			seen := map[string]bool{"": true, "hdd": true, "ssd": true, "nvme": true}
+			for _, t := range cache.DiskTypes {
+				if !seen[t] {
+					seen[t] = true
+					types = append(types, t)
+				}
+			}
+		}
+		return types
+	})
+}
+
+// PredictRacks completes a -rack flag with the rack ids from the cached cluster state. It is also
+// registered for -dataCenter, where it offers the same rack ids: data center names are not
+// collected into the cache.
+func PredictRacks() complete.Predictor {
+	return complete.PredictFunc(func(complete.Args) []string {
+		cache := predictorSnapshot()
+		if cache == nil {
+			return nil
+		}
+		return cache.Racks
+	})
+}
+
+// defaultFlagPredictors maps well-known flag names, shared by convention across weed's
+// subcommands, to the predictor that makes sense for them. AutocompleteMain consults this for
+// any flag a Command didn't already cover via its own FlagPredictors, so a command gets useful
+// completion for its -dir/-collection/-volumeId/... flags without having to opt in by name.
+var defaultFlagPredictors = map[string]complete.Predictor{
+	"dir":          complete.PredictFiles("*"),
+	"config":       complete.PredictFiles("*"),
+	"filer.path":   complete.PredictFiles("*"),
+	"filer.dir":    complete.PredictFiles("*"),
+	"master.dir":   complete.PredictFiles("*"),
+	"logdir":       complete.PredictFiles("*"),
+	"collection":   PredictCollections(),
+	"volumeId":     PredictVolumeIds(),
+	"vid":          PredictVolumeIds(),
+	"diskType":     PredictDiskTypes(),
+	"fromDiskType": PredictDiskTypes(),
+	"toDiskType":   PredictDiskTypes(),
+	"rack":         PredictRacks(),
+	"dataCenter":   PredictRacks(),
+}
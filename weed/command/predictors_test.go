@@ -0,0 +1,78 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/master_pb"
+	"github.com/posener/complete"
+)
+
+func TestCollectFromTopology(t *testing.T) {
+	topo := &master_pb.TopologyInfo{
+		DataCenterInfos: []*master_pb.DataCenterInfo{
+			{
+				Id: "dc1",
+				RackInfos: []*master_pb.RackInfo{
+					{
+						Id: "rack1",
+						DataNodeInfos: []*master_pb.DataNodeInfo{
+							{
+								Id: "dn1",
+								DiskInfos: map[string]*master_pb.DiskInfo{
+									"hdd": {
+										VolumeInfos: []*master_pb.VolumeInformationMessage{
+											{Id: 1, Collection: "c1"},
+											{Id: 2, Collection: "c2"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	collections := map[string]struct{}{}
+	volumeIds := map[string]struct{}{}
+	diskTypes := map[string]struct{}{}
+	racks := map[string]struct{}{}
+	collectFromTopology(topo, collections, volumeIds, diskTypes, racks)
+
+	if _, ok := racks["rack1"]; !ok {
+		t.Errorf("expected rack1 to be collected, got %v", racks)
+	}
+	if _, ok := diskTypes["hdd"]; !ok {
+		t.Errorf("expected hdd to be collected, got %v", diskTypes)
+	}
+	if _, ok := collections["c1"]; !ok {
+		t.Errorf("expected c1 to be collected, got %v", collections)
+	}
+	if _, ok := volumeIds["1"]; !ok {
+		t.Errorf("expected volume id 1 to be collected, got %v", volumeIds)
+	}
+}
+
+func TestPredictorForFlagPrefersCommandOverDefault(t *testing.T) {
+	cmd := &Command{
+		FlagPredictors: map[string]complete.Predictor{
+			"dir": complete.PredictNothing,
+		},
+	}
+
+	// the command's own PredictNothing (a nil Predictor) wins over the default file predictor.
+	if got := predictorForFlag(cmd, "dir"); got != complete.PredictNothing {
+		t.Fatalf("expected the command's own PredictNothing for dir, got %v", got)
+	}
+
+	// a flag the command didn't register falls back to the name-based default.
+	if got := predictorForFlag(cmd, "config"); got == nil {
+		t.Fatalf("expected config to fall back to the default file predictor, got nil")
+	}
+
+	// an entirely unknown flag falls back to PredictAnything.
+	if got := predictorForFlag(nil, "some-unknown-flag"); got == nil {
+		t.Fatalf("expected a non-nil fallback predictor")
+	}
+}
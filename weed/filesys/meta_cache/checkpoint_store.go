@@ -0,0 +1,211 @@
+package meta_cache
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// CheckpointStore persists the highest filer event timestamp processed by SubscribeMetaEvents, so
+// that a crashed "weed mount", "filer.remote.sync" or "filer.backup" process can resume close to
+// where it left off instead of replaying the full event history from tsNs=0.
+type CheckpointStore interface {
+	// Load returns the last saved tsNs for (filerSignature, subscribePath), or 0 if none was saved.
+	Load(filerSignature int32, subscribePath string) int64
+	// Save records tsNs as the latest processed event for (filerSignature, subscribePath).
+	// Implementations may buffer writes and flush them periodically rather than fsync on every call.
+	Save(filerSignature int32, subscribePath string, tsNs int64)
+	// Reset wipes any checkpoint recorded for subscribePath, used when the local meta cache is
+	// rebuilt from scratch and the next subscription should start wherever the caller now wants.
+	Reset(subscribePath string)
+	// Shutdown flushes any buffered checkpoint and releases the underlying store.
+	Shutdown()
+}
+
+type checkpointKey struct {
+	filerSignature int32
+	subscribePath  string
+}
+
+// checkpointKeySeparator joins the filerSignature prefix to subscribePath. A NUL byte is used
+// instead of a printable character since filer paths cannot contain one, so splitting a stored
+// key on its first occurrence unambiguously recovers subscribePath even if subscribePath itself
+// contains characters like ':' or '/'.
+const checkpointKeySeparator = "\x00"
+
+func (k checkpointKey) Bytes() []byte {
+	return []byte(fmt.Sprintf("%d%s%s", k.filerSignature, checkpointKeySeparator, k.subscribePath))
+}
+
+// subscribePathOfKey returns the subscribePath portion of a stored key, as produced by Bytes().
+func subscribePathOfKey(key []byte) string {
+	if i := bytes.IndexByte(key, checkpointKeySeparator[0]); i >= 0 {
+		return string(key[i+1:])
+	}
+	return ""
+}
+
+// LevelDbCheckpointStore is the default CheckpointStore, a leveldb-backed store keyed by
+// (filerSignature, subscribePath) that batches writes so fsync cost stays bounded: it flushes
+// every flushEveryEvents saves or every flushInterval, whichever comes first.
+type LevelDbCheckpointStore struct {
+	db *leveldb.DB
+
+	flushEveryEvents int
+	flushInterval    time.Duration
+
+	mu          sync.Mutex
+	pending     map[checkpointKey]int64
+	eventsSince int
+
+	closeOnce sync.Once
+	stopCh    chan struct{}
+}
+
+// defaultFlushEveryEvents and defaultFlushInterval are used whenever the caller passes a
+// non-positive value, so a zero-value config flag cannot turn into a busy-loop or a panic.
+const (
+	defaultFlushEveryEvents = 100
+	defaultFlushInterval    = 5 * time.Second
+)
+
+func NewLevelDbCheckpointStore(dbFolder string, flushEveryEvents int, flushInterval time.Duration) (*LevelDbCheckpointStore, error) {
+	db, err := leveldb.OpenFile(dbFolder, nil)
+	if err != nil {
+		return nil, fmt.Errorf("open checkpoint store %s: %v", dbFolder, err)
+	}
+
+	if flushEveryEvents <= 0 {
+		flushEveryEvents = defaultFlushEveryEvents
+	}
+	if flushInterval <= 0 {
+		flushInterval = defaultFlushInterval
+	}
+
+	store := &LevelDbCheckpointStore{
+		db:               db,
+		flushEveryEvents: flushEveryEvents,
+		flushInterval:    flushInterval,
+		pending:          make(map[checkpointKey]int64),
+		stopCh:           make(chan struct{}),
+	}
+	go store.flushLoop()
+	return store, nil
+}
+
+func (s *LevelDbCheckpointStore) Load(filerSignature int32, subscribePath string) int64 {
+	key := checkpointKey{filerSignature, subscribePath}
+
+	s.mu.Lock()
+	if tsNs, found := s.pending[key]; found {
+		s.mu.Unlock()
+		return tsNs
+	}
+	s.mu.Unlock()
+
+	value, err := s.db.Get(key.Bytes(), nil)
+	if err != nil {
+		if err != leveldb.ErrNotFound {
+			glog.V(0).Infof("load checkpoint for %s: %v", subscribePath, err)
+		}
+		return 0
+	}
+	tsNs, err := decodeCheckpointValue(value)
+	if err != nil {
+		glog.V(0).Infof("decode checkpoint for %s: %v", subscribePath, err)
+		return 0
+	}
+	return tsNs
+}
+
+func (s *LevelDbCheckpointStore) Save(filerSignature int32, subscribePath string, tsNs int64) {
+	key := checkpointKey{filerSignature, subscribePath}
+
+	s.mu.Lock()
+	s.pending[key] = tsNs
+	s.eventsSince++
+	shouldFlush := s.eventsSince >= s.flushEveryEvents
+	s.mu.Unlock()
+
+	if shouldFlush {
+		s.flush()
+	}
+}
+
+func (s *LevelDbCheckpointStore) Reset(subscribePath string) {
+	s.mu.Lock()
+	for key := range s.pending {
+		if key.subscribePath == subscribePath {
+			delete(s.pending, key)
+		}
+	}
+	s.mu.Unlock()
+
+	iter := s.db.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		key := iter.Key()
+		if subscribePathOfKey(key) == subscribePath {
+			if err := s.db.Delete(key, nil); err != nil {
+				glog.V(0).Infof("reset checkpoint for %s: %v", subscribePath, err)
+			}
+		}
+	}
+}
+
+func (s *LevelDbCheckpointStore) Shutdown() {
+	s.closeOnce.Do(func() {
+		close(s.stopCh)
+		s.flush()
+		if err := s.db.Close(); err != nil {
+			glog.V(0).Infof("close checkpoint store: %v", err)
+		}
+	})
+}
+
+func (s *LevelDbCheckpointStore) flushLoop() {
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+func (s *LevelDbCheckpointStore) flush() {
+	s.mu.Lock()
+	if len(s.pending) == 0 {
+		s.mu.Unlock()
+		return
+	}
+	toWrite := s.pending
+	s.pending = make(map[checkpointKey]int64)
+	s.eventsSince = 0
+	s.mu.Unlock()
+
+	batch := new(leveldb.Batch)
+	for key, tsNs := range toWrite {
+		batch.Put(key.Bytes(), encodeCheckpointValue(tsNs))
+	}
+	if err := s.db.Write(batch, nil); err != nil {
+		glog.V(0).Infof("flush checkpoint store: %v", err)
+	}
+}
+
+func encodeCheckpointValue(tsNs int64) []byte {
+	return []byte(fmt.Sprintf("%d", tsNs))
+}
+
+func decodeCheckpointValue(value []byte) (int64, error) {
+	var tsNs int64
+	_, err := fmt.Sscanf(string(value), "%d", &tsNs)
+	return tsNs, err
+}
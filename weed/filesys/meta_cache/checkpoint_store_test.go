@@ -0,0 +1,89 @@
+package meta_cache
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestCheckpointStore(t *testing.T) *LevelDbCheckpointStore {
+	store, err := NewLevelDbCheckpointStore(t.TempDir(), 1, time.Hour)
+	if err != nil {
+		t.Fatalf("NewLevelDbCheckpointStore: %v", err)
+	}
+	t.Cleanup(store.Shutdown)
+	return store
+}
+
+func TestLevelDbCheckpointStoreSaveLoad(t *testing.T) {
+	store := newTestCheckpointStore(t)
+
+	if tsNs := store.Load(1, "/buckets/a"); tsNs != 0 {
+		t.Fatalf("expected 0 for an unseen key, got %d", tsNs)
+	}
+
+	store.Save(1, "/buckets/a", 100)
+	if tsNs := store.Load(1, "/buckets/a"); tsNs != 100 {
+		t.Fatalf("expected 100, got %d", tsNs)
+	}
+
+	// a different subscribePath under the same filerSignature must not collide, even though the
+	// stored key is built by concatenating the two fields.
+	store.Save(1, "/buckets/a/b", 200)
+	if tsNs := store.Load(1, "/buckets/a"); tsNs != 100 {
+		t.Fatalf("expected /buckets/a to stay at 100, got %d", tsNs)
+	}
+	if tsNs := store.Load(1, "/buckets/a/b"); tsNs != 200 {
+		t.Fatalf("expected /buckets/a/b to be 200, got %d", tsNs)
+	}
+}
+
+func TestLevelDbCheckpointStoreSaveFlushesToDisk(t *testing.T) {
+	store := newTestCheckpointStore(t)
+
+	store.Save(1, "/buckets/a", 42)
+	store.flush()
+
+	value, err := store.db.Get(checkpointKey{1, "/buckets/a"}.Bytes(), nil)
+	if err != nil {
+		t.Fatalf("Get after flush: %v", err)
+	}
+	tsNs, err := decodeCheckpointValue(value)
+	if err != nil {
+		t.Fatalf("decodeCheckpointValue: %v", err)
+	}
+	if tsNs != 42 {
+		t.Fatalf("expected 42, got %d", tsNs)
+	}
+}
+
+func TestLevelDbCheckpointStoreReset(t *testing.T) {
+	store := newTestCheckpointStore(t)
+
+	store.Save(1, "/buckets/a", 100)
+	store.Save(1, "/buckets/a/b", 200)
+	store.flush()
+
+	store.Reset("/buckets/a")
+
+	if tsNs := store.Load(1, "/buckets/a"); tsNs != 0 {
+		t.Fatalf("expected /buckets/a to be reset to 0, got %d", tsNs)
+	}
+	if tsNs := store.Load(1, "/buckets/a/b"); tsNs != 200 {
+		t.Fatalf("expected /buckets/a/b to be unaffected by resetting /buckets/a, got %d", tsNs)
+	}
+}
+
+func TestLevelDbCheckpointStoreResetPendingNotYetFlushed(t *testing.T) {
+	store, err := NewLevelDbCheckpointStore(t.TempDir(), 1000, time.Hour)
+	if err != nil {
+		t.Fatalf("NewLevelDbCheckpointStore: %v", err)
+	}
+	t.Cleanup(store.Shutdown)
+
+	store.Save(1, "/buckets/a", 100)
+	store.Reset("/buckets/a")
+
+	if tsNs := store.Load(1, "/buckets/a"); tsNs != 0 {
+		t.Fatalf("expected a reset to drop even an unflushed pending save, got %d", tsNs)
+	}
+}
@@ -9,7 +9,19 @@ import (
 	"github.com/chrislusf/seaweedfs/weed/util"
 )
 
-func SubscribeMetaEvents(mc *MetaCache, selfSignature int32, client filer_pb.FilerClient, dir string, lastTsNs int64) error {
+// SubscribeMetaEvents follows filer metadata events under dir, applying each one to mc. If
+// checkpointStore is non-nil, it is consulted for a saved cursor before lastTsNs is used, and it
+// is updated after every successfully applied event so a restart does not have to replay the
+// entries already seen.
+func SubscribeMetaEvents(mc *MetaCache, selfSignature int32, client filer_pb.FilerClient, dir string, lastTsNs int64, checkpointStore CheckpointStore) error {
+
+	subscribePath := dir
+
+	if checkpointStore != nil {
+		if savedTsNs := checkpointStore.Load(selfSignature, subscribePath); savedTsNs > lastTsNs {
+			lastTsNs = savedTsNs
+		}
+	}
 
 	processEventFn := func(resp *filer_pb.SubscribeMetadataResponse) error {
 		message := resp.EventNotification
@@ -38,6 +50,10 @@ func SubscribeMetaEvents(mc *MetaCache, selfSignature int32, client filer_pb.Fil
 		}
 		err := mc.AtomicUpdateEntryFromFiler(context.Background(), oldPath, newEntry)
 		if err == nil {
+			lastTsNs = resp.TsNs
+			if checkpointStore != nil {
+				checkpointStore.Save(selfSignature, subscribePath, resp.TsNs)
+			}
 			if message.OldEntry != nil && message.NewEntry != nil {
 				if message.OldEntry.Name == message.NewEntry.Name {
 					// no need to invalidate
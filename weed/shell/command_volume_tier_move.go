@@ -1,9 +1,14 @@
 package shell
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/pb"
 	"github.com/chrislusf/seaweedfs/weed/pb/master_pb"
+	"github.com/chrislusf/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/chrislusf/seaweedfs/weed/storage/super_block"
 	"github.com/chrislusf/seaweedfs/weed/storage/types"
 	"github.com/chrislusf/seaweedfs/weed/wdclient"
 	"io"
@@ -33,8 +38,10 @@ func (c *commandVolumeTierMove) Help() string {
 
 	volume.tier.move -fromDiskType=hdd -toDiskType=ssd [-collectionPattern=""] [-fullPercent=95] [-quietFor=1h]
 
-	Even if the volume is replicated, only one replica will be changed and the rest replicas will be dropped.
-	So "volume.fix.replication" and "volume.balance" should be followed.
+	By default, every replica of a matching volume is moved to the target disk type, keeping the
+	volume's existing replication level intact. Pass -keepReplication=false to fall back to the old
+	behavior of moving a single replica and dropping the rest, which then requires
+	"volume.fix.replication" and "volume.balance" to be run afterward.
 
 `
 }
@@ -54,6 +61,7 @@ func (c *commandVolumeTierMove) Do(args []string, commandEnv *CommandEnv, writer
 	quietPeriod := tierCommand.Duration("quietFor", 24*time.Hour, "select volumes without no writes for this period")
 	source := tierCommand.String("fromDiskType", "", "the source disk type")
 	target := tierCommand.String("toDiskType", "", "the target disk type")
+	keepReplication := tierCommand.Bool("keepReplication", true, "move every replica of a volume, keeping its existing replication level")
 	applyChange := tierCommand.Bool("force", false, "actually apply the changes")
 	if err = tierCommand.Parse(args); err != nil {
 		return nil
@@ -81,7 +89,7 @@ func (c *commandVolumeTierMove) Do(args []string, commandEnv *CommandEnv, writer
 
 	_, allLocations := collectVolumeReplicaLocations(topologyInfo)
 	for _, vid := range volumeIds {
-		if err = c.doVolumeTierMove(commandEnv, writer, vid, toDiskType, allLocations, *applyChange); err != nil {
+		if err = c.doVolumeTierMove(commandEnv, writer, topologyInfo, vid, toDiskType, allLocations, *applyChange, *keepReplication); err != nil {
 			fmt.Printf("tier move volume %d: %v\n", vid, err)
 		}
 	}
@@ -98,18 +106,29 @@ func isOneOf(server string, locations []wdclient.Location) bool {
 	return false
 }
 
-func (c *commandVolumeTierMove) doVolumeTierMove(commandEnv *CommandEnv, writer io.Writer, vid needle.VolumeId, toDiskType types.DiskType, allLocations []location, applyChanges bool) (err error) {
+func (c *commandVolumeTierMove) doVolumeTierMove(commandEnv *CommandEnv, writer io.Writer, topologyInfo *master_pb.TopologyInfo, vid needle.VolumeId, toDiskType types.DiskType, allLocations []location, applyChanges, keepReplication bool) (err error) {
 	// find volume location
 	locations, found := commandEnv.MasterClient.GetLocations(uint32(vid))
 	if !found {
 		return fmt.Errorf("volume %d not found", vid)
 	}
 
+	if keepReplication {
+		return c.doVolumeTierMoveKeepReplication(commandEnv, writer, topologyInfo, vid, toDiskType, allLocations, locations, applyChanges)
+	}
+
+	return c.doVolumeTierMoveDropReplication(commandEnv, writer, vid, toDiskType, allLocations, locations, applyChanges)
+}
+
+// doVolumeTierMoveDropReplication is the original behavior: move a single replica to the target
+// tier and drop the rest, relying on a later "volume.fix.replication" and "volume.balance" to
+// restore the desired replication level.
+func (c *commandVolumeTierMove) doVolumeTierMoveDropReplication(commandEnv *CommandEnv, writer io.Writer, vid needle.VolumeId, toDiskType types.DiskType, allLocations []location, locations []wdclient.Location, applyChanges bool) (err error) {
+
 	// find one server with the most empty volume slots with target disk type
 	hasFoundTarget := false
 	keepDataNodesSorted(allLocations, toDiskType)
 	fn := capacityByFreeVolumeCount(toDiskType)
-	wg := sync.WaitGroup{}
 	for _, dst := range allLocations {
 		if fn(dst.dataNode) > 0 && !hasFoundTarget {
 			// ask the volume server to replicate the volume
@@ -134,34 +153,14 @@ func (c *commandVolumeTierMove) doVolumeTierMove(commandEnv *CommandEnv, writer
 				break
 			}
 
-			c.activeServersCond.L.Lock()
-			_, isSourceActive := c.activeServers[sourceVolumeServer]
-			_, isDestActive := c.activeServers[dst.dataNode.Id]
-			for isSourceActive || isDestActive {
-				c.activeServersCond.Wait()
-				_, isSourceActive = c.activeServers[sourceVolumeServer]
-				_, isDestActive = c.activeServers[dst.dataNode.Id]
+			c.waitForActiveServers(sourceVolumeServer, dst.dataNode.Id)
+			if err := c.doMoveOneVolume(commandEnv, writer, vid, toDiskType, locations, sourceVolumeServer, dst); err != nil {
+				fmt.Fprintf(writer, "move volume %d %s => %s: %v\n", vid, sourceVolumeServer, dst.dataNode.Id, err)
 			}
-			c.activeServers[sourceVolumeServer] = struct{}{}
-			c.activeServers[dst.dataNode.Id] = struct{}{}
-			c.activeServersCond.L.Unlock()
-
-			wg.Add(1)
-			go func(dst location) {
-				if err := c.doMoveOneVolume(commandEnv, writer, vid, toDiskType, locations, sourceVolumeServer, dst); err != nil {
-					fmt.Fprintf(writer, "move volume %d %s => %s: %v\n", vid, sourceVolumeServer, dst.dataNode.Id, err)
-				}
-				delete(c.activeServers, sourceVolumeServer)
-				delete(c.activeServers, dst.dataNode.Id)
-				c.activeServersCond.Signal()
-				wg.Done()
-			}(dst)
-
+			c.releaseActiveServers(sourceVolumeServer, dst.dataNode.Id)
 		}
 	}
 
-	wg.Wait()
-
 	if !hasFoundTarget {
 		fmt.Fprintf(writer, "can not find disk type %s for volume %d\n", toDiskType.ReadableString(), vid)
 	}
@@ -169,6 +168,172 @@ func (c *commandVolumeTierMove) doVolumeTierMove(commandEnv *CommandEnv, writer
 	return nil
 }
 
+// doVolumeTierMoveKeepReplication moves every replica of vid to the target disk type, honoring
+// the volume's placement rules, and only removes the old-tier copies once all of them have landed
+// and been verified on the new tier.
+func (c *commandVolumeTierMove) doVolumeTierMoveKeepReplication(commandEnv *CommandEnv, writer io.Writer, topologyInfo *master_pb.TopologyInfo, vid needle.VolumeId, toDiskType types.DiskType, allLocations []location, locations []wdclient.Location, applyChanges bool) (err error) {
+
+	rp, err := lookupReplicaPlacement(topologyInfo, vid)
+	if err != nil {
+		return err
+	}
+	if expected := rp.GetCopyCount(); expected != len(locations) {
+		fmt.Fprintf(writer, "volume %d has %d replica(s) on record but placement %s expects %d, moving what exists\n", vid, len(locations), rp.String(), expected)
+	}
+	alreadyOnTarget := locationsOnDiskType(topologyInfo, vid, toDiskType)
+
+	keepDataNodesSorted(allLocations, toDiskType)
+	fn := capacityByFreeVolumeCount(toDiskType)
+
+	type replicaMove struct {
+		sourceServer string
+		dest         location
+	}
+
+	// finalDcRacks tracks the dc/rack of every replica that will make up the volume once this move
+	// is done, starting with the replicas that are already on the target tier and growing as
+	// destinations are picked below, so each pick can be checked against rp's diversity rule.
+	var finalDcRacks []dcRack
+	for _, loc := range locations {
+		if alreadyOnTarget[loc.Url] {
+			if dr, found := locationDcRack(allLocations, loc.Url); found {
+				finalDcRacks = append(finalDcRacks, dr)
+			}
+		}
+	}
+
+	usedDest := make(map[string]struct{})
+	var moves []replicaMove
+	var unmovable []string
+	for _, loc := range locations {
+		if alreadyOnTarget[loc.Url] {
+			continue
+		}
+		sourceDcRack, _ := locationDcRack(allLocations, loc.Url)
+
+		dest := pickDiversifiedDestination(allLocations, fn, usedDest, locations, rp, finalDcRacks, sourceDcRack)
+		if dest == nil {
+			unmovable = append(unmovable, loc.Url)
+			continue
+		}
+		usedDest[dest.dataNode.Id] = struct{}{}
+		finalDcRacks = append(finalDcRacks, dcRack{dc: dest.dc, rack: dest.rack})
+		moves = append(moves, replicaMove{sourceServer: loc.Url, dest: *dest})
+	}
+
+	if len(unmovable) > 0 {
+		// Do not delete any old-tier replica: with fewer destinations than replicas, deleting the
+		// ones we did find homes for would drop the volume below its configured replication level.
+		return fmt.Errorf("can not find a disk type %s destination satisfying placement %s for volume %d replica(s) on %v, skipping this volume", toDiskType.ReadableString(), rp.String(), vid, unmovable)
+	}
+
+	if len(moves) == 0 {
+		fmt.Fprintf(writer, "volume %d already has all %d replica(s) on disk type %s\n", vid, len(locations), toDiskType.ReadableString())
+		return nil
+	}
+
+	if !applyChanges {
+		for _, mv := range moves {
+			fmt.Fprintf(writer, "moving volume %d from %s to %s with disk type %s ...\n", vid, mv.sourceServer, mv.dest.dataNode.Id, toDiskType.ReadableString())
+			mv.dest.dataNode.DiskInfos[string(toDiskType)].VolumeCount++
+		}
+		return nil
+	}
+
+	if err = markVolumeReadonly(commandEnv.option.GrpcDialOption, vid, locations); err != nil {
+		return fmt.Errorf("mark volume %d as readonly on %s: %v", vid, locations[0].Url, err)
+	}
+
+	var movedTo []string
+	for _, mv := range moves {
+		c.waitForActiveServers(mv.sourceServer, mv.dest.dataNode.Id)
+		fmt.Fprintf(writer, "moving volume %d from %s to %s with disk type %s ...\n", vid, mv.sourceServer, mv.dest.dataNode.Id, toDiskType.ReadableString())
+		moveErr := LiveMoveVolume(commandEnv.option.GrpcDialOption, writer, vid, mv.sourceServer, mv.dest.dataNode.Id, 5*time.Second, toDiskType.ReadableString(), true)
+		if moveErr == nil {
+			moveErr = verifyVolumeOnServer(commandEnv, vid, mv.dest.dataNode.Id)
+		}
+		c.releaseActiveServers(mv.sourceServer, mv.dest.dataNode.Id)
+
+		if moveErr != nil {
+			fmt.Fprintf(writer, "move volume %d %s => %s: %v, rolling back\n", vid, mv.sourceServer, mv.dest.dataNode.Id, moveErr)
+			for _, done := range movedTo {
+				if delErr := deleteVolume(commandEnv.option.GrpcDialOption, vid, done); delErr != nil {
+					fmt.Fprintf(writer, "failed to roll back volume %d on %s: %v\n", vid, done, delErr)
+				}
+			}
+			// Nothing on the old tier was deleted, so every original replica is still around:
+			// undo the readonly lock from above instead of leaving the volume stuck unwritable.
+			if writableErr := markVolumeWritable(commandEnv.option.GrpcDialOption, vid, locations); writableErr != nil {
+				fmt.Fprintf(writer, "failed to mark volume %d writable again on rollback: %v\n", vid, writableErr)
+			}
+			return fmt.Errorf("move volume %d %s => %s : %v", vid, mv.sourceServer, mv.dest.dataNode.Id, moveErr)
+		}
+
+		mv.dest.dataNode.DiskInfos[string(toDiskType)].VolumeCount++
+		movedTo = append(movedTo, mv.dest.dataNode.Id)
+	}
+
+	// every replica now lives on the target tier: drop the old-tier copies
+	for _, loc := range locations {
+		if alreadyOnTarget[loc.Url] {
+			continue
+		}
+		if err = deleteVolume(commandEnv.option.GrpcDialOption, vid, loc.Url); err != nil {
+			fmt.Fprintf(writer, "failed to delete old-tier volume %d on %s: %v\n", vid, loc.Url, err)
+		}
+	}
+
+	// the volume now lives on: the replicas that were already on the target tier, plus every
+	// replica just moved there. All of them were locked readonly above and need to be writable
+	// again, including the ones skipped by the move/delete loops because alreadyOnTarget was true.
+	survivors := make([]wdclient.Location, 0, len(locations))
+	for _, loc := range locations {
+		if alreadyOnTarget[loc.Url] {
+			survivors = append(survivors, loc)
+		}
+	}
+	for _, id := range movedTo {
+		survivors = append(survivors, wdclient.Location{Url: id})
+	}
+	if err = markVolumeWritable(commandEnv.option.GrpcDialOption, vid, survivors); err != nil {
+		fmt.Fprintf(writer, "failed to mark volume %d writable after move: %v\n", vid, err)
+	}
+
+	return nil
+}
+
+// waitForActiveServers blocks until none of the given servers are involved in another
+// in-flight move, then reserves them for the caller.
+func (c *commandVolumeTierMove) waitForActiveServers(servers ...string) {
+	c.activeServersCond.L.Lock()
+	defer c.activeServersCond.L.Unlock()
+	for {
+		busy := false
+		for _, server := range servers {
+			if _, ok := c.activeServers[server]; ok {
+				busy = true
+				break
+			}
+		}
+		if !busy {
+			break
+		}
+		c.activeServersCond.Wait()
+	}
+	for _, server := range servers {
+		c.activeServers[server] = struct{}{}
+	}
+}
+
+func (c *commandVolumeTierMove) releaseActiveServers(servers ...string) {
+	c.activeServersCond.L.Lock()
+	for _, server := range servers {
+		delete(c.activeServers, server)
+	}
+	c.activeServersCond.Signal()
+	c.activeServersCond.L.Unlock()
+}
+
 func (c *commandVolumeTierMove) doMoveOneVolume(commandEnv *CommandEnv, writer io.Writer, vid needle.VolumeId, toDiskType types.DiskType, locations []wdclient.Location, sourceVolumeServer string, dst location) (err error) {
 
 	// mark all replicas as read only
@@ -176,6 +341,11 @@ func (c *commandVolumeTierMove) doMoveOneVolume(commandEnv *CommandEnv, writer i
 		return fmt.Errorf("mark volume %d as readonly on %s: %v", vid, locations[0].Url, err)
 	}
 	if err = LiveMoveVolume(commandEnv.option.GrpcDialOption, writer, vid, sourceVolumeServer, dst.dataNode.Id, 5*time.Second, toDiskType.ReadableString(), true); err != nil {
+		// the move never happened: every original replica is still in place, so undo the
+		// readonly lock instead of leaving the volume stuck unwritable.
+		if writableErr := markVolumeWritable(commandEnv.option.GrpcDialOption, vid, locations); writableErr != nil {
+			fmt.Fprintf(writer, "failed to mark volume %d writable again on rollback: %v\n", vid, writableErr)
+		}
 		return fmt.Errorf("move volume %d %s => %s : %v", vid, locations[0].Url, dst.dataNode.Id, err)
 	}
 
@@ -190,9 +360,142 @@ func (c *commandVolumeTierMove) doMoveOneVolume(commandEnv *CommandEnv, writer i
 			}
 		}
 	}
+
+	// LiveMoveVolume removed the source and the loop above the other replicas: the volume now
+	// lives only on dst, so mark it writable again.
+	survivors := []wdclient.Location{{Url: dst.dataNode.Id}}
+	if err = markVolumeWritable(commandEnv.option.GrpcDialOption, vid, survivors); err != nil {
+		fmt.Fprintf(writer, "failed to mark volume %d writable after move: %v\n", vid, err)
+	}
 	return nil
 }
 
+// lookupReplicaPlacement returns the replication rule a volume was created with, as recorded in
+// the topology information collected from the master.
+func lookupReplicaPlacement(topologyInfo *master_pb.TopologyInfo, vid needle.VolumeId) (rp *super_block.ReplicaPlacement, err error) {
+	eachDataNode(topologyInfo, func(dc string, rack RackId, dn *master_pb.DataNodeInfo) {
+		for _, diskInfo := range dn.DiskInfos {
+			for _, v := range diskInfo.VolumeInfos {
+				if needle.VolumeId(v.Id) == vid {
+					rp, err = super_block.NewReplicaPlacementFromByte(byte(v.ReplicaPlacement))
+				}
+			}
+		}
+	})
+	if rp == nil && err == nil {
+		err = fmt.Errorf("replica placement for volume %d not found", vid)
+	}
+	return rp, err
+}
+
+// dcRack is the data center and rack a data node belongs to.
+type dcRack struct {
+	dc   string
+	rack RackId
+}
+
+// locationDcRack looks up the dc/rack of a data node by its id, as recorded in allLocations.
+func locationDcRack(allLocations []location, nodeId string) (dcRack, bool) {
+	for _, loc := range allLocations {
+		if loc.dataNode.Id == nodeId {
+			return dcRack{dc: loc.dc, rack: loc.rack}, true
+		}
+	}
+	return dcRack{}, false
+}
+
+// satisfiesReplicaPlacement reports whether adding candidate to the dc/racks already committed to
+// (finalDcRacks) still fits within rp: at most rp.DiffDataCenterCount+1 distinct data centers, and
+// within any one data center at most rp.DiffRackCount+1 distinct racks.
+func satisfiesReplicaPlacement(rp *super_block.ReplicaPlacement, finalDcRacks []dcRack, candidate dcRack) bool {
+	racksByDc := map[string]map[RackId]bool{}
+	for _, dr := range finalDcRacks {
+		if racksByDc[dr.dc] == nil {
+			racksByDc[dr.dc] = map[RackId]bool{}
+		}
+		racksByDc[dr.dc][dr.rack] = true
+	}
+	if racksByDc[candidate.dc] == nil {
+		racksByDc[candidate.dc] = map[RackId]bool{}
+	}
+	racksByDc[candidate.dc][candidate.rack] = true
+
+	if len(racksByDc) > rp.DiffDataCenterCount+1 {
+		return false
+	}
+	for _, racks := range racksByDc {
+		if len(racks) > rp.DiffRackCount+1 {
+			return false
+		}
+	}
+	return true
+}
+
+// pickDiversifiedDestination chooses a free destination on the target tier for one replica. Among
+// the candidates that keep the move within rp's dc/rack diversity rule, it prefers the one closest
+// to the replica's current location (same rack, then same dc) to minimize unnecessary churn.
+func pickDiversifiedDestination(allLocations []location, fn func(*master_pb.DataNodeInfo) int, usedDest map[string]struct{}, original []wdclient.Location, rp *super_block.ReplicaPlacement, finalDcRacks []dcRack, source dcRack) *location {
+	var best *location
+	bestScore := -1
+	for i := range allLocations {
+		candidate := allLocations[i]
+		if fn(candidate.dataNode) <= 0 {
+			continue
+		}
+		if _, used := usedDest[candidate.dataNode.Id]; used {
+			continue
+		}
+		if isOneOf(candidate.dataNode.Id, original) {
+			continue
+		}
+		candidateDcRack := dcRack{dc: candidate.dc, rack: candidate.rack}
+		if !satisfiesReplicaPlacement(rp, finalDcRacks, candidateDcRack) {
+			continue
+		}
+		score := 0
+		switch {
+		case candidateDcRack == source:
+			score = 2
+		case candidateDcRack.dc == source.dc:
+			score = 1
+		}
+		if score > bestScore {
+			bestScore = score
+			best = &candidate
+		}
+	}
+	return best
+}
+
+// locationsOnDiskType reports which data node ids already hold a copy of vid on diskType.
+func locationsOnDiskType(topologyInfo *master_pb.TopologyInfo, vid needle.VolumeId, diskType types.DiskType) map[string]bool {
+	onDiskType := make(map[string]bool)
+	eachDataNode(topologyInfo, func(dc string, rack RackId, dn *master_pb.DataNodeInfo) {
+		for dt, diskInfo := range dn.DiskInfos {
+			if types.ToDiskType(dt) != diskType {
+				continue
+			}
+			for _, v := range diskInfo.VolumeInfos {
+				if needle.VolumeId(v.Id) == vid {
+					onDiskType[dn.Id] = true
+				}
+			}
+		}
+	})
+	return onDiskType
+}
+
+// verifyVolumeOnServer confirms the moved volume is actually readable on its new home before the
+// old-tier replica is deleted.
+func verifyVolumeOnServer(commandEnv *CommandEnv, vid needle.VolumeId, server string) error {
+	return operation.WithVolumeServerClient(false, pb.ServerAddress(server), commandEnv.option.GrpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+		_, err := client.ReadVolumeFileStatus(context.Background(), &volume_server_pb.ReadVolumeFileStatusRequest{
+			VolumeId: uint32(vid),
+		})
+		return err
+	})
+}
+
 func collectVolumeIdsForTierChange(commandEnv *CommandEnv, topologyInfo *master_pb.TopologyInfo, volumeSizeLimitMb uint64, sourceTier types.DiskType, collectionPattern string, fullPercentage float64, quietPeriod time.Duration) (vids []needle.VolumeId, err error) {
 
 	quietSeconds := int64(quietPeriod / time.Second)
@@ -0,0 +1,71 @@
+package shell
+
+import (
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/storage/super_block"
+)
+
+func TestSatisfiesReplicaPlacementSameDcSameRack(t *testing.T) {
+	// "000": 1 copy total, no dc/rack diversity allowed at all.
+	rp, err := super_block.NewReplicaPlacementFromByte(0)
+	if err != nil {
+		t.Fatalf("NewReplicaPlacementFromByte(0): %v", err)
+	}
+
+	existing := []dcRack{{dc: "dc1", rack: "rack1"}}
+
+	if !satisfiesReplicaPlacement(rp, existing, dcRack{dc: "dc1", rack: "rack1"}) {
+		t.Errorf("expected same dc/rack candidate to be allowed when already present")
+	}
+	if satisfiesReplicaPlacement(rp, existing, dcRack{dc: "dc1", rack: "rack2"}) {
+		t.Errorf("expected a second rack in the same dc to be rejected: rp allows no rack diversity")
+	}
+	if satisfiesReplicaPlacement(rp, existing, dcRack{dc: "dc2", rack: "rack1"}) {
+		t.Errorf("expected a second dc to be rejected: rp allows no dc diversity")
+	}
+}
+
+func TestSatisfiesReplicaPlacementRackDiversity(t *testing.T) {
+	// "010": one extra rack allowed within the same dc, no extra dc.
+	rp, err := super_block.NewReplicaPlacementFromByte(10)
+	if err != nil {
+		t.Fatalf("NewReplicaPlacementFromByte(10): %v", err)
+	}
+
+	existing := []dcRack{{dc: "dc1", rack: "rack1"}}
+
+	if !satisfiesReplicaPlacement(rp, existing, dcRack{dc: "dc1", rack: "rack2"}) {
+		t.Errorf("expected a second rack in the same dc to be allowed")
+	}
+	if satisfiesReplicaPlacement(rp, existing, dcRack{dc: "dc2", rack: "rack1"}) {
+		t.Errorf("expected a second dc to be rejected: rp allows no dc diversity")
+	}
+
+	existing = append(existing, dcRack{dc: "dc1", rack: "rack2"})
+	if satisfiesReplicaPlacement(rp, existing, dcRack{dc: "dc1", rack: "rack3"}) {
+		t.Errorf("expected a third rack in the same dc to be rejected: only one extra rack is allowed")
+	}
+}
+
+func TestSatisfiesReplicaPlacementDcDiversity(t *testing.T) {
+	// "100": one extra dc allowed, no extra rack within a dc.
+	rp, err := super_block.NewReplicaPlacementFromByte(100)
+	if err != nil {
+		t.Fatalf("NewReplicaPlacementFromByte(100): %v", err)
+	}
+
+	existing := []dcRack{{dc: "dc1", rack: "rack1"}}
+
+	if !satisfiesReplicaPlacement(rp, existing, dcRack{dc: "dc2", rack: "rack1"}) {
+		t.Errorf("expected a second dc to be allowed")
+	}
+	if satisfiesReplicaPlacement(rp, existing, dcRack{dc: "dc1", rack: "rack2"}) {
+		t.Errorf("expected a second rack in the same dc to be rejected: rp allows no rack diversity")
+	}
+
+	existing = append(existing, dcRack{dc: "dc2", rack: "rack1"})
+	if satisfiesReplicaPlacement(rp, existing, dcRack{dc: "dc3", rack: "rack1"}) {
+		t.Errorf("expected a third dc to be rejected: only one extra dc is allowed")
+	}
+}